@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// RepoConfig holds per-repo integration settings loaded from the -config
+// YAML file.
+type RepoConfig struct {
+	Trello struct {
+		Enabled bool              `yaml:"enabled"`
+		Lists   map[string]string `yaml:"lists"`
+	} `yaml:"trello"`
+}
+
+// FileConfig is the shape of the YAML file passed via -config, mapping a
+// repository's "owner/name" path to its settings.
+type FileConfig struct {
+	Repos map[string]RepoConfig `yaml:"repos"`
+}
+
+// loadFileConfig reads and parses the YAML config file at path. An empty
+// path returns a zero-value FileConfig so integrations relying on it are
+// simply disabled.
+func loadFileConfig(path string) (FileConfig, error) {
+	var c FileConfig
+
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return c, err
+	}
+
+	err = yaml.Unmarshal(data, &c)
+
+	return c, err
+}
@@ -0,0 +1,194 @@
+// Package githubAuth authenticates as a GitHub App installation, as an
+// alternative to a static personal access token.
+package githubAuth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+const apiBaseURL = "https://api.github.com"
+
+// AppConfig holds the credentials needed to authenticate as a GitHub App.
+type AppConfig struct {
+	AppID          int64
+	PrivateKeyPath string
+
+	// InstallationID is the app installation to mint tokens for. If zero,
+	// the first installation returned by the App installations API is used.
+	InstallationID int64
+}
+
+// InstallationTokenSource is an oauth2.TokenSource that mints and caches
+// GitHub App installation access tokens, refreshing them on demand as they
+// approach expiry.
+type InstallationTokenSource struct {
+	config     AppConfig
+	privateKey *rsa.PrivateKey
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewInstallationTokenSource loads the app's private key from disk and
+// returns a token source that mints installation tokens for it.
+func NewInstallationTokenSource(config AppConfig) (*InstallationTokenSource, error) {
+	keyBytes, err := ioutil.ReadFile(config.PrivateKeyPath)
+
+	if err != nil {
+		return nil, fmt.Errorf("githubAuth: unable to read private key: %s", err)
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("githubAuth: unable to parse private key: %s", err)
+	}
+
+	return &InstallationTokenSource{config: config, privateKey: key}, nil
+}
+
+// Token implements the oauth2.TokenSource interface, returning the cached
+// installation token if it still has more than a minute left, or minting a
+// fresh one otherwise.
+func (s *InstallationTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && s.token.Expiry.After(time.Now().Add(time.Minute)) {
+		return s.token, nil
+	}
+
+	appJWT, err := s.signAppJWT()
+
+	if err != nil {
+		return nil, err
+	}
+
+	installationID := s.config.InstallationID
+
+	if installationID == 0 {
+		installationID, err = discoverInstallationID(appJWT)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, expiresAt, err := fetchInstallationToken(appJWT, installationID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.token = &oauth2.Token{AccessToken: accessToken, Expiry: expiresAt}
+
+	return s.token, nil
+}
+
+// signAppJWT creates a short-lived JWT identifying the app, as required to
+// call the App installations and access token endpoints.
+func (s *InstallationTokenSource) signAppJWT() (string, error) {
+	now := time.Now()
+
+	claims := jwt.StandardClaims{
+		IssuedAt:  now.Add(-time.Minute).Unix(),
+		ExpiresAt: now.Add(10 * time.Minute).Unix(),
+		Issuer:    fmt.Sprintf("%d", s.config.AppID),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(s.privateKey)
+}
+
+type installation struct {
+	ID int64 `json:"id"`
+}
+
+// discoverInstallationID returns the first installation available to the
+// app, for the common case of a single-org app with no configured ID.
+func discoverInstallationID(appJWT string) (int64, error) {
+	req, err := http.NewRequest("GET", apiBaseURL+"/app/installations", nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	setAppJWTHeaders(req, appJWT)
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("githubAuth: unexpected status listing installations: %s", resp.Status)
+	}
+
+	var installations []installation
+
+	if err := json.NewDecoder(resp.Body).Decode(&installations); err != nil {
+		return 0, err
+	}
+
+	if len(installations) == 0 {
+		return 0, fmt.Errorf("githubAuth: app has no installations")
+	}
+
+	return installations[0].ID, nil
+}
+
+type installationTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// fetchInstallationToken exchanges an app JWT for an installation access
+// token, as described at
+// https://docs.github.com/en/rest/apps/apps#create-an-installation-access-token-for-an-app
+func fetchInstallationToken(appJWT string, installationID int64) (string, time.Time, error) {
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", apiBaseURL, installationID)
+
+	req, err := http.NewRequest("POST", url, nil)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	setAppJWTHeaders(req, appJWT)
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("githubAuth: unexpected status minting installation token: %s", resp.Status)
+	}
+
+	var tokenResp installationTokenResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenResp.Token, tokenResp.ExpiresAt, nil
+}
+
+func setAppJWTHeaders(req *http.Request, appJWT string) {
+	req.Header.Set("Authorization", "Bearer "+appJWT)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+}
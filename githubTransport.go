@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxTransportRetries bounds how many times a single request is attempted
+	// in all, the first try included, on secondary rate limiting / abuse
+	// detection or server errors.
+	maxTransportRetries = 5
+
+	// rateLimitFloor is the X-RateLimit-Remaining threshold below which new
+	// requests are paused until the window resets.
+	rateLimitFloor = 50
+)
+
+// rateLimitedTransport wraps an http.RoundTripper so that it backs off when
+// GitHub's primary rate limit is nearly exhausted, and retries secondary
+// rate limit / abuse detection responses and 5xx errors with backoff.
+type rateLimitedTransport struct {
+	base http.RoundTripper
+
+	mu        sync.Mutex
+	remaining int
+	resetAt   time.Time
+}
+
+// newRateLimitedTransport wraps base with rate limit and retry handling.
+func newRateLimitedTransport(base http.RoundTripper) *rateLimitedTransport {
+	return &rateLimitedTransport{base: base, remaining: -1}
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.waitForCapacity()
+
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; attempt < maxTransportRetries; attempt++ {
+		if attempt > 0 {
+			if rerr := rewindRequestBody(req); rerr != nil {
+				return nil, rerr
+			}
+		}
+
+		resp, err = t.base.RoundTrip(req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t.recordRateLimit(resp)
+
+		retrying := attempt < maxTransportRetries-1
+
+		if resp.StatusCode == http.StatusForbidden {
+			isAbuse, wait := abuseBackoff(resp, attempt)
+
+			if !isAbuse || !retrying {
+				return resp, nil
+			}
+
+			resp.Body.Close()
+			log.Warn("github secondary rate limit hit, backing off", "wait", wait, "attempt", attempt+1)
+			time.Sleep(wait)
+			continue
+		}
+
+		if resp.StatusCode >= 500 {
+			if !retrying {
+				return resp, nil
+			}
+
+			wait := jitteredBackoff(attempt)
+			resp.Body.Close()
+
+			log.Warn("github server error, retrying", "status", resp.StatusCode, "wait", wait, "attempt", attempt+1)
+			time.Sleep(wait)
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return resp, err
+}
+
+// waitForCapacity blocks until the primary rate limit window resets, if the
+// last seen X-RateLimit-Remaining has dropped below rateLimitFloor.
+func (t *rateLimitedTransport) waitForCapacity() {
+	t.mu.Lock()
+	remaining, resetAt := t.remaining, t.resetAt
+	t.mu.Unlock()
+
+	if remaining < 0 || remaining >= rateLimitFloor {
+		return
+	}
+
+	if wait := time.Until(resetAt); wait > 0 {
+		log.Warn("approaching github rate limit, pausing until reset", "remaining", remaining, "wait", wait)
+		time.Sleep(wait)
+	}
+}
+
+func (t *rateLimitedTransport) recordRateLimit(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+
+	if err != nil {
+		return
+	}
+
+	resetUnix, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.remaining = remaining
+	t.resetAt = time.Unix(resetUnix, 0)
+	t.mu.Unlock()
+}
+
+// abuseBackoff inspects a 403 response for signs of GitHub's secondary rate
+// limiting (an abuse-detection Retry-After header, or a body mentioning
+// "abuse"), and returns how long to wait before retrying. resp.Body is
+// restored to its full, unread state afterwards, so a caller that doesn't
+// retry can still read the complete response.
+func abuseBackoff(resp *http.Response, attempt int) (isAbuse bool, wait time.Duration) {
+	retryAfter := resp.Header.Get("Retry-After")
+
+	bodyBytes, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	originalBody := resp.Body
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(bodyBytes), originalBody), originalBody}
+
+	if retryAfter == "" && !strings.Contains(strings.ToLower(string(bodyBytes)), "abuse") {
+		return false, 0
+	}
+
+	if secs, err := strconv.Atoi(retryAfter); err == nil {
+		return true, time.Duration(secs) * time.Second
+	}
+
+	return true, jitteredBackoff(attempt)
+}
+
+func jitteredBackoff(attempt int) time.Duration {
+	base := time.Duration(1<<uint(attempt)) * time.Second
+	jitter := time.Duration(rand.Int63n(int64(time.Second)))
+
+	return base + jitter
+}
+
+// rewindRequestBody resets req.Body from req.GetBody so a request with a
+// body can be safely retried.
+func rewindRequestBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+
+	if err != nil {
+		return err
+	}
+
+	req.Body = body
+
+	return nil
+}
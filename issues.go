@@ -106,6 +106,128 @@ func updateIssueReviewLabels(client *github.Client, log log15.Logger, review Rev
 	return nil
 }
 
+const reviewStatusContext = "cake-bot/review"
+
+// lastReviewStatus tracks the last "sha:label" combination posted for each
+// PR, keyed by "owner/repo#number", so updateReviewStatus doesn't repost a
+// status when nothing has changed.
+var lastReviewStatus = struct {
+	sync.Mutex
+	seen map[string]string
+}{seen: map[string]string{}}
+
+// statusStateForLabel maps a review label to the commit status / check run
+// state and description that should be reported for it.
+func statusStateForLabel(label string) (state, description string) {
+	switch label {
+	case CakedLabel:
+		return "success", "PR has been reviewed and caked"
+	case AwaitingCakeLabel:
+		return "pending", "PR is awaiting review"
+	default:
+		return "pending", "PR is a work in progress"
+	}
+}
+
+// updateReviewStatus posts a commit status against the PR's head commit
+// reflecting its current cake/WIP state and, when running as a GitHub App,
+// an equivalent check run. It is a no-op if neither the head SHA nor the
+// review label have changed since the last update.
+func updateReviewStatus(client *github.Client, log log15.Logger, review ReviewRequest) error {
+	pr, _, err := client.PullRequests.Get(*review.repo.Owner.Login, *review.repo.Name, review.Number())
+
+	if err != nil {
+		log.Error("unable to fetch pull request", "err", err)
+		return err
+	}
+
+	sha := *pr.Head.SHA
+	label := review.CalculateAppropriateStatus()
+	key := fmt.Sprintf("%s#%d", review.RepositoryPath(), review.Number())
+	seenValue := sha + ":" + label
+
+	lastReviewStatus.Lock()
+	unchanged := lastReviewStatus.seen[key] == seenValue
+	lastReviewStatus.Unlock()
+
+	if unchanged {
+		log.Info("review status does not need updating", "sha", sha, "label", label)
+		return nil
+	}
+
+	state, description := statusStateForLabel(label)
+	statusContext := reviewStatusContext
+
+	status := &github.RepoStatus{
+		State:       &state,
+		Context:     &statusContext,
+		Description: &description,
+		TargetURL:   review.issue.HTMLURL,
+	}
+
+	if _, _, err := client.Repositories.CreateStatus(*review.repo.Owner.Login, *review.repo.Name, sha, status); err != nil {
+		log.Error("unable to create commit status", "err", err)
+		return err
+	}
+
+	if usingGithubApp {
+		if err := createCheckRun(client, review, sha, label, state, description); err != nil {
+			log.Error("unable to create check run", "err", err)
+			return err
+		}
+	}
+
+	lastReviewStatus.Lock()
+	lastReviewStatus.seen[key] = seenValue
+	lastReviewStatus.Unlock()
+
+	return nil
+}
+
+// checkRunRequest is the body of a POST /repos/{owner}/{repo}/check-runs
+// request. go-github predates the Checks API, so it's built and sent by
+// hand using the client's generic request/do helpers.
+type checkRunRequest struct {
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion,omitempty"`
+	Output     struct {
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	} `json:"output"`
+}
+
+func createCheckRun(client *github.Client, review ReviewRequest, sha, label, state, description string) error {
+	body := checkRunRequest{
+		Name:    reviewStatusContext,
+		HeadSHA: sha,
+		Status:  "in_progress",
+	}
+
+	if state == "success" {
+		body.Status = "completed"
+		body.Conclusion = "success"
+	}
+
+	body.Output.Title = fmt.Sprintf("cake-bot: %s", label)
+	body.Output.Summary = description
+
+	url := fmt.Sprintf("repos/%s/%s/check-runs", *review.repo.Owner.Login, *review.repo.Name)
+
+	req, err := client.NewRequest("POST", url, body)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.antiope-preview+json")
+
+	_, err = client.Do(req, nil)
+
+	return err
+}
+
 type Issue struct {
 	github.Issue
 
@@ -272,9 +394,11 @@ func ensureOrgReposHaveLabels(org string, client *github.Client) error {
 		}
 
 		for _, r := range repos {
+			r := r
+
 			wg.Add(1)
 
-			go func(r github.Repository) {
+			workers.Go(func() {
 				defer wg.Done()
 				log.Info("start syncing labels for repo", "repo.name", *r.Name)
 				err := setupReviewFlagsInRepo(r, client)
@@ -284,7 +408,7 @@ func ensureOrgReposHaveLabels(org string, client *github.Client) error {
 				}
 
 				log.Info("done syncing labels for repo", "repo.name", *r.Name)
-			}(r)
+			})
 		}
 
 		if resp.NextPage == 0 {
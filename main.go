@@ -1,29 +1,50 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/geckoboard/goutils/router"
 	github "github.com/google/go-github/github"
 	"github.com/julienschmidt/httprouter"
 	"golang.org/x/oauth2"
 	log15 "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/timothee-alby/cake-bot/githubAuth"
 )
 
 var (
 	GithubApiKey string
 	log          log15.Logger
 	gh           *github.Client
+
+	webhookSecret string
+
+	// usingGithubApp is true when the bot authenticated via a GitHub App
+	// installation token rather than a static personal access token, which
+	// unlocks GitHub App-only APIs such as check runs.
+	usingGithubApp bool
+
+	// workers bounds how many requests ensureOrgReposHaveLabels and the
+	// per-PR fan-out in runBulkSync may have in flight at once.
+	workers *workQueue
 )
 
 type Config struct {
-	Port      int
-	GithubOrg string
+	Port           int
+	GithubOrg      string
+	ConfigPath     string
+	Concurrency    int
+	SyncInterval   time.Duration
+	StaleThreshold time.Duration
 }
 
 // tokenSource is an oauth2.TokenSource which returns a static access token
@@ -36,9 +57,57 @@ func (t *tokenSource) Token() (*oauth2.Token, error) {
 	return t.token, nil
 }
 
+// tokenSourceFromEnv builds the oauth2.TokenSource the bot should authenticate
+// with: a GitHub App installation token source if GITHUB_APP_ID is set, or a
+// static personal access token otherwise.
+func tokenSourceFromEnv() (oauth2.TokenSource, error) {
+	appID := os.Getenv("GITHUB_APP_ID")
+
+	if appID == "" {
+		token := os.Getenv("GITHUB_ACCESS_TOKEN")
+
+		if token == "" {
+			return nil, fmt.Errorf("neither GITHUB_APP_ID nor GITHUB_ACCESS_TOKEN specified")
+		}
+
+		return &tokenSource{&oauth2.Token{AccessToken: token}}, nil
+	}
+
+	id, err := strconv.ParseInt(appID, 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid GITHUB_APP_ID: %s", err)
+	}
+
+	var installationID int64
+
+	if raw := os.Getenv("GITHUB_APP_INSTALLATION_ID"); raw != "" {
+		installationID, err = strconv.ParseInt(raw, 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid GITHUB_APP_INSTALLATION_ID: %s", err)
+		}
+	}
+
+	ts, err := githubAuth.NewInstallationTokenSource(githubAuth.AppConfig{
+		AppID:          id,
+		PrivateKeyPath: os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH"),
+		InstallationID: installationID,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	usingGithubApp = true
+
+	return ts, nil
+}
+
 func NewServer() http.Handler {
 	r := router.New()
 	r.GET("/ping", ping)
+	r.GET("/metrics", metrics)
 	r.POST("/github", githubWebhook)
 	return r
 }
@@ -60,19 +129,40 @@ func githubWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params)
 		return
 	}
 
+	body, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		l.Error("could not read request body", "err", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	if !verifyWebhookSignature(webhookSecret, body, r) {
+		l.Error("webhook signature missing or invalid")
+		w.WriteHeader(401)
+		return
+	}
+
+	deliveryID := r.Header.Get(deliveryIDHeader)
+
+	if isDuplicateDelivery(deliveryID) {
+		l.Info("ignoring replayed delivery", "delivery_id", deliveryID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	var payload struct {
 		Action      string
 		Issue       *github.Issue
 		Repository  *github.Repository
 		PullRequest *github.PullRequest
 	}
-	var err error
 
 	var triggerInspection bool
 
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&payload); err != nil {
 		l.Error("could not unmarshal json", "err", err)
-		w.WriteHeader(501)
+		w.WriteHeader(400)
 		return
 	}
 
@@ -122,8 +212,19 @@ func githubWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params)
 			w.WriteHeader(501)
 			return
 		}
+
+		if err := updateReviewStatus(gh, l, pr); err != nil {
+			w.WriteHeader(501)
+			return
+		}
+
+		if err := updateTrelloCards(l, pr); err != nil {
+			w.WriteHeader(501)
+			return
+		}
 	}
 
+	markDeliveryProcessed(deliveryID)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -133,7 +234,6 @@ func runBulkSync(c Config) {
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		return
 
 		err := ensureOrgReposHaveLabels(c.GithubOrg, gh)
 
@@ -156,10 +256,15 @@ func runBulkSync(c Config) {
 
 			wg.Add(1)
 
-			go func(pr ReviewRequest, l log15.Logger) {
+			pr, l := pr, l
+
+			workers.Go(func() {
+				defer wg.Done()
+
 				updateIssueReviewLabels(gh, l, pr)
-				wg.Done()
-			}(pr, l)
+				updateReviewStatus(gh, l, pr)
+				updateTrelloCards(l, pr)
+			})
 		}
 	}()
 
@@ -176,25 +281,44 @@ func main() {
 
 	flag.IntVar(&c.Port, "port", 0, "port to run http server on, if not set server does not run")
 	flag.StringVar(&c.GithubOrg, "github-org", "geckoboard", "the github org to manage issues for")
+	flag.StringVar(&webhookSecret, "webhook-secret", os.Getenv("GITHUB_WEBHOOK_SECRET"), "shared secret used to verify github webhook payloads")
+	flag.StringVar(&c.ConfigPath, "config", "", "path to a YAML config file for per-repo integrations, e.g. trello")
+	flag.IntVar(&c.Concurrency, "concurrency", 8, "maximum number of repo label and PR sync jobs run concurrently against the github api")
+	flag.DurationVar(&c.SyncInterval, "sync-interval", time.Hour, "how often to re-sync the whole org in the background")
+	flag.DurationVar(&c.StaleThreshold, "stale-threshold", 3*24*time.Hour, "how long an awaiting-cake PR may go without updates before it gets a reminder comment")
 	flag.Parse()
 
-	token := os.Getenv("GITHUB_ACCESS_TOKEN")
+	if webhookSecret == "" {
+		log.Warn("GITHUB_WEBHOOK_SECRET not specified, webhook signatures will not be verified")
+	}
 
-	if token == "" {
-		log.Error("GITHUB_ACCESS_TOKEN not specified")
+	fileConfig, err := loadFileConfig(c.ConfigPath)
+
+	if err != nil {
+		log.Error("could not load config file", "path", c.ConfigPath, "err", err)
 		os.Exit(1)
 	}
 
-	ts := &tokenSource{
-		&oauth2.Token{AccessToken: token},
+	setupTrello(fileConfig)
+
+	ts, err := tokenSourceFromEnv()
+
+	if err != nil {
+		log.Error("could not configure github authentication", "err", err)
+		os.Exit(1)
 	}
 
 	tc := oauth2.NewClient(oauth2.NoContext, ts)
+	tc.Transport = newRateLimitedTransport(tc.Transport)
 
 	gh = github.NewClient(tc)
 
+	workers = newWorkQueue(c.Concurrency)
+
 	runBulkSync(c)
 
+	go runScheduledSync(c, c.SyncInterval, c.StaleThreshold)
+
 	if c.Port > 0 {
 		httpServer := http.Server{
 			Addr:    fmt.Sprintf(":%d", c.Port),
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	prsByState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cake_bot_prs_by_state",
+		Help: "Number of PRs in each cake/WIP review state, as of the last sync.",
+	}, []string{"state"})
+
+	lastSyncDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cake_bot_last_sync_duration_seconds",
+		Help: "Duration of the most recently completed org sync, in seconds.",
+	})
+
+	nudgesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cake_bot_nudges_sent_total",
+		Help: "Total number of stale-PR reminder comments posted.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(prsByState, lastSyncDuration, nudgesSent)
+}
+
+func metrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	promhttp.Handler().ServeHTTP(w, r)
+}
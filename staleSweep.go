@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	github "github.com/google/go-github/github"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// nudgeMarker identifies a reminder comment cake-bot has already posted, so
+// nudgeStalePRs doesn't post a second one on the next sweep.
+const nudgeMarker = "<!-- cake-bot:nudge -->"
+
+// runScheduledSync re-runs the org-wide sync every interval, in addition to
+// the webhook-driven updates, so that PRs which stop receiving events are
+// still periodically re-evaluated. It blocks forever and should be run in
+// its own goroutine.
+func runScheduledSync(c Config, interval, staleThreshold time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		start := time.Now()
+
+		runBulkSync(c)
+		nudgeStalePRs(c, staleThreshold)
+
+		lastSyncDuration.Set(time.Since(start).Seconds())
+	}
+}
+
+// nudgeStalePRs posts a single reminder comment on awaiting-cake PRs whose
+// issue has not been updated for at least staleThreshold, and records the current
+// split of PRs by review state for the /metrics endpoint.
+func nudgeStalePRs(c Config, staleThreshold time.Duration) {
+	reviews, err := ReviewRequestsInOrg(gh, c.GithubOrg)
+
+	if err != nil {
+		log.Error("could not load issues for stale pr sweep", "err", err)
+		return
+	}
+
+	counts := map[string]int{}
+
+	for _, review := range reviews {
+		status := review.CalculateAppropriateStatus()
+		counts[status]++
+
+		if status != AwaitingCakeLabel || time.Since(*review.issue.UpdatedAt) < staleThreshold {
+			continue
+		}
+
+		l := log.New("issue.number", review.Number(), "issue.url", review.URL())
+
+		if err := nudgeIfNeeded(l, review); err != nil {
+			l.Error("could not post stale pr reminder", "err", err)
+		}
+	}
+
+	for _, state := range []string{WIPLabel, CakedLabel, AwaitingCakeLabel} {
+		prsByState.WithLabelValues(state).Set(float64(counts[state]))
+	}
+}
+
+// nudgeIfNeeded posts a reminder comment on review's PR, unless it already
+// carries one.
+func nudgeIfNeeded(l log15.Logger, review ReviewRequest) error {
+	for _, c := range review.comments {
+		if strings.Contains(*c.Body, nudgeMarker) {
+			return nil
+		}
+	}
+
+	body := fmt.Sprintf("This PR has been awaiting cake for a while, could someone take a look? %s", nudgeMarker)
+
+	_, _, err := gh.Issues.CreateComment(*review.repo.Owner.Login, *review.repo.Name, review.Number(), &github.IssueComment{Body: &body})
+
+	if err != nil {
+		return err
+	}
+
+	nudgesSent.Inc()
+	l.Info("posted stale pr reminder")
+
+	return nil
+}
@@ -0,0 +1,203 @@
+// Package trello attaches pull requests to the Trello cards referenced in
+// their body or comments, and moves those cards between lists as the PR's
+// cake/WIP status changes.
+package trello
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+const apiBaseURL = "https://api.trello.com/1"
+
+// Client talks to the Trello REST API using an API key + token pair.
+type Client struct {
+	APIKey string
+	Token  string
+
+	httpClient *http.Client
+}
+
+// NewClient returns a Client authenticated with the given API key and token.
+func NewClient(apiKey, token string) *Client {
+	return &Client{APIKey: apiKey, Token: token, httpClient: http.DefaultClient}
+}
+
+func (c *Client) do(method, path string, query url.Values, v interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	query.Set("key", c.APIKey)
+	query.Set("token", c.Token)
+
+	req, err := http.NewRequest(method, apiBaseURL+path+"?"+query.Encode(), nil)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("trello: %s %s returned %s", method, path, resp.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+var cardShortLinkRegex = regexp.MustCompile(`trello\.com/c/([a-zA-Z0-9]+)`)
+
+// ErrNotCardURL is returned by CardIDFromURL when the given URL is a trello.com
+// link that doesn't reference a card, e.g. a board URL.
+var ErrNotCardURL = errors.New("trello: url does not reference a card")
+
+// CardIDFromURL resolves a Trello card URL, as scraped from a PR body or
+// comment, to its full card ID. It returns ErrNotCardURL if cardURL is not a
+// card link.
+func (c *Client) CardIDFromURL(cardURL string) (string, error) {
+	match := cardShortLinkRegex.FindStringSubmatch(cardURL)
+
+	if match == nil {
+		return "", ErrNotCardURL
+	}
+
+	var card struct {
+		ID string `json:"id"`
+	}
+
+	if err := c.do("GET", fmt.Sprintf("/cards/%s", match[1]), nil, &card); err != nil {
+		return "", err
+	}
+
+	return card.ID, nil
+}
+
+func (c *Client) attachmentURLs(cardID string) ([]string, error) {
+	var attachments []struct {
+		URL string `json:"url"`
+	}
+
+	if err := c.do("GET", fmt.Sprintf("/cards/%s/attachments", cardID), nil, &attachments); err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, len(attachments))
+
+	for i, a := range attachments {
+		urls[i] = a.URL
+	}
+
+	return urls, nil
+}
+
+// AttachPR attaches prURL to the card, unless it is already attached.
+func (c *Client) AttachPR(cardID, prURL string) error {
+	existing, err := c.attachmentURLs(cardID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, u := range existing {
+		if u == prURL {
+			return nil
+		}
+	}
+
+	return c.do("POST", fmt.Sprintf("/cards/%s/attachments", cardID), url.Values{"url": {prURL}}, nil)
+}
+
+// MoveToList moves the card to listID.
+func (c *Client) MoveToList(cardID, listID string) error {
+	return c.do("PUT", fmt.Sprintf("/cards/%s", cardID), url.Values{"idList": {listID}}, nil)
+}
+
+// Comment posts a short comment on the card.
+func (c *Client) Comment(cardID, text string) error {
+	return c.do("POST", fmt.Sprintf("/cards/%s/actions/comments", cardID), url.Values{"text": {text}}, nil)
+}
+
+// Sync keeps a repository's Trello cards in step with PR review status: it
+// attaches PRs to the cards they reference and moves those cards between
+// lists as the review status changes.
+type Sync struct {
+	Client  *Client
+	ListIDs map[string]string
+
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+// NewSync returns a Sync that moves cards between the lists in listIDs,
+// keyed by review status.
+func NewSync(client *Client, listIDs map[string]string) *Sync {
+	return &Sync{Client: client, ListIDs: listIDs, seen: map[string]string{}}
+}
+
+// UpdateCardsForPR attaches prURL to every card in cardURLs and, if status
+// has changed since the last call for key, moves each card to its
+// configured list and leaves a comment recording the transition. Referenced
+// URLs that aren't card links, e.g. board URLs, are skipped and logged
+// rather than failing the whole batch.
+func (s *Sync) UpdateCardsForPR(log log15.Logger, key, prURL, status string, cardURLs []string) error {
+	s.mu.Lock()
+	changed := s.seen[key] != status
+	s.mu.Unlock()
+
+	listID, hasList := s.ListIDs[status]
+
+	for _, cardURL := range cardURLs {
+		cardID, err := s.Client.CardIDFromURL(cardURL)
+
+		if err == ErrNotCardURL {
+			log.Info("skipping trello url that does not reference a card", "url", cardURL)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.Client.AttachPR(cardID, prURL); err != nil {
+			return err
+		}
+
+		if !changed || !hasList {
+			continue
+		}
+
+		if err := s.Client.MoveToList(cardID, listID); err != nil {
+			return err
+		}
+
+		if err := s.Client.Comment(cardID, fmt.Sprintf("%s is now %s", prURL, status)); err != nil {
+			return err
+		}
+	}
+
+	if changed {
+		s.mu.Lock()
+		s.seen[key] = status
+		s.mu.Unlock()
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	log15 "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/timothee-alby/cake-bot/trello"
+)
+
+var (
+	trelloClient *trello.Client
+	trelloConfig FileConfig
+
+	trelloSyncs = struct {
+		sync.Mutex
+		byRepo map[string]*trello.Sync
+	}{byRepo: map[string]*trello.Sync{}}
+)
+
+// setupTrello configures the Trello integration from TRELLO_API_KEY /
+// TRELLO_TOKEN and the given file config. It is a no-op, silently disabling
+// the integration, if either credential is missing.
+func setupTrello(config FileConfig) {
+	apiKey := os.Getenv("TRELLO_API_KEY")
+	token := os.Getenv("TRELLO_TOKEN")
+
+	if apiKey == "" || token == "" {
+		return
+	}
+
+	trelloClient = trello.NewClient(apiKey, token)
+	trelloConfig = config
+}
+
+// trelloSyncForRepo returns the Sync for repoPath, or nil if Trello isn't
+// configured or isn't enabled for that repo.
+func trelloSyncForRepo(repoPath string) *trello.Sync {
+	if trelloClient == nil {
+		return nil
+	}
+
+	repoConfig, ok := trelloConfig.Repos[repoPath]
+
+	if !ok || !repoConfig.Trello.Enabled {
+		return nil
+	}
+
+	trelloSyncs.Lock()
+	defer trelloSyncs.Unlock()
+
+	if s, ok := trelloSyncs.byRepo[repoPath]; ok {
+		return s
+	}
+
+	s := trello.NewSync(trelloClient, repoConfig.Trello.Lists)
+	trelloSyncs.byRepo[repoPath] = s
+
+	return s
+}
+
+// updateTrelloCards attaches review to any Trello cards referenced in its
+// body/comments and moves them between lists as its status changes, if
+// Trello integration is configured and enabled for the repo.
+func updateTrelloCards(log log15.Logger, review ReviewRequest) error {
+	s := trelloSyncForRepo(review.RepositoryPath())
+
+	if s == nil {
+		return nil
+	}
+
+	cardURLs := review.ExtractTrelloCardUrls()
+
+	if len(cardURLs) == 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s#%d", review.RepositoryPath(), review.Number())
+
+	if err := s.UpdateCardsForPR(log, key, review.URL(), review.CalculateAppropriateStatus(), cardURLs); err != nil {
+		log.Error("unable to sync trello cards", "err", err)
+		return err
+	}
+
+	return nil
+}
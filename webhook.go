@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	sha256SignatureHeader = "X-Hub-Signature-256"
+	sha1SignatureHeader   = "X-Hub-Signature"
+	deliveryIDHeader      = "X-GitHub-Delivery"
+)
+
+// seenDeliveries tracks GitHub webhook delivery IDs we've already processed
+// so that retried or replayed deliveries are ignored.
+var seenDeliveries = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: map[string]bool{}}
+
+// isDuplicateDelivery reports whether id has already been processed.
+func isDuplicateDelivery(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	seenDeliveries.Lock()
+	defer seenDeliveries.Unlock()
+
+	return seenDeliveries.ids[id]
+}
+
+// markDeliveryProcessed records id as successfully handled, so that a
+// redelivery of the same event is recognised by isDuplicateDelivery. It
+// should only be called once the delivery has been fully handled, so that a
+// failed delivery is retried by GitHub rather than silently dropped.
+func markDeliveryProcessed(id string) {
+	if id == "" {
+		return
+	}
+
+	seenDeliveries.Lock()
+	defer seenDeliveries.Unlock()
+
+	seenDeliveries.ids[id] = true
+}
+
+// verifyWebhookSignature checks body against the X-Hub-Signature-256 header,
+// falling back to the older sha1 X-Hub-Signature header for older deliveries.
+// If no secret is configured, verification is skipped and it returns true, so
+// that running without a secret behaves as it did before signature
+// verification was added. It returns false if a secret is configured but no
+// valid signature is present.
+func verifyWebhookSignature(secret string, body []byte, r *http.Request) bool {
+	if secret == "" {
+		return true
+	}
+
+	if sig := r.Header.Get(sha256SignatureHeader); sig != "" {
+		return checkSignature(sha256.New, secret, body, sig, "sha256=")
+	}
+
+	if sig := r.Header.Get(sha1SignatureHeader); sig != "" {
+		return checkSignature(sha1.New, secret, body, sig, "sha1=")
+	}
+
+	return false
+}
+
+func checkSignature(newHash func() hash.Hash, secret string, body []byte, header, prefix string) bool {
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(header, prefix))
+
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,26 @@
+package main
+
+// workQueue bounds how many goroutines spawned through it may run at once,
+// replacing the unbounded `go func() {...}()` fan-outs that used to hit the
+// GitHub API without limit.
+type workQueue struct {
+	sem chan struct{}
+}
+
+// newWorkQueue returns a workQueue that allows at most concurrency jobs to
+// run at the same time.
+func newWorkQueue(concurrency int) *workQueue {
+	return &workQueue{sem: make(chan struct{}, concurrency)}
+}
+
+// Go runs fn in a new goroutine once a slot is free, blocking the caller
+// until one is. It does not wait for fn to finish; pair with the caller's
+// own sync.WaitGroup if that's needed.
+func (q *workQueue) Go(fn func()) {
+	q.sem <- struct{}{}
+
+	go func() {
+		defer func() { <-q.sem }()
+		fn()
+	}()
+}